@@ -1,9 +1,21 @@
 package app
 
 import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/mosteligible/mcp-codemode/coderunner/config"
+	"github.com/mosteligible/mcp-codemode/coderunner/core/executor"
+	"github.com/mosteligible/mcp-codemode/coderunner/core/handlers"
+	"github.com/mosteligible/mcp-codemode/coderunner/core/sandbox"
 	"github.com/mosteligible/mcp-codemode/coderunner/middlewares"
+	"github.com/mosteligible/mcp-codemode/coderunner/observability"
 	"github.com/mosteligible/mcp-codemode/coderunner/states"
 )
 
@@ -11,12 +23,21 @@ type App struct {
 	wrapper             http.Handler
 	port                string
 	availableContainers states.ContainerState
+	server              *http.Server
+	idle                *idleTracker
+	rootCtx             context.Context
+	rootCancel          context.CancelFunc
+	shutdownTracing     func(context.Context) error
 }
 
 func NewApp(port string) *App {
+	rootCtx, rootCancel := context.WithCancel(context.Background())
 	app := &App{
 		port:                port,
 		availableContainers: *states.NewContainerState(),
+		idle:                &idleTracker{},
+		rootCtx:             rootCtx,
+		rootCancel:          rootCancel,
 	}
 	app.init()
 	return app
@@ -25,11 +46,105 @@ func NewApp(port string) *App {
 func (a *App) init() {
 	mux := http.NewServeMux()
 
-	a.wrapper = middlewares.LoggingMiddleware(mux)
+	shutdownTracing, err := observability.InitTracer()
+	if err != nil {
+		slog.Error("Failed to start tracer, spans will be no-ops", "error", err)
+	} else {
+		a.shutdownTracing = shutdownTracing
+	}
+
+	if config.Conf.UseDockerExecutor() {
+		exec, err := executor.New(a.rootCtx, &a.availableContainers)
+		if err != nil {
+			slog.Error("Failed to start docker executor, falling back to ssh", "error", err)
+		} else {
+			executor.Default = exec
+		}
+	}
+
+	var pool *states.Pool
+	pool, err = states.NewPool(config.Conf.Pool.SizePerLanguage)
+	if err != nil {
+		slog.Error("Failed to create sandbox container pool, falling back to container-per-request", "error", err)
+		pool = nil
+	} else {
+		if err := pool.Start(a.rootCtx); err != nil {
+			slog.Error("Sandbox container pool warm-up had failures, continuing with a partially warmed pool", "error", err)
+		}
+		for lang := range config.Conf.Pool.SizePerLanguage {
+			observability.PoolAvailable.WithLabelValues(lang).Set(float64(pool.Depth(lang)))
+		}
+	}
+
+	box, err := sandbox.New(pool)
+	if err != nil {
+		slog.Error("Failed to start sandbox executor, per-language runs will fall back to the configured executor", "error", err)
+	} else {
+		sandbox.Default = box
+	}
+
+	mux.HandleFunc("POST /run", handlers.RunCode)
+	mux.HandleFunc("POST /run/stream", handlers.StreamRun)
+	mux.Handle("GET /metrics", observability.Handler())
+
+	// This chain applies to /metrics as much as to /run, so any endpoint
+	// added later gets logging, tracing and draining for free.
+	a.wrapper = a.idle.wrap(middlewares.LoggingMiddleware(observability.Middleware(mux)))
+
+	// Every request's context is derived from rootCtx, so cancelling it on
+	// shutdown aborts in-flight container exec calls immediately instead of
+	// waiting for clients to notice the connection closed.
+	a.server = &http.Server{
+		Addr:    a.port,
+		Handler: a.wrapper,
+		BaseContext: func(net.Listener) context.Context {
+			return a.rootCtx
+		},
+	}
 }
 
+// Start serves until SIGINT/SIGTERM, then drains in-flight requests before
+// returning: it stops accepting new connections (http.Server.Shutdown),
+// waits for the idle tracker to hit zero, and finally cancels the root
+// context so any exec/docker calls still attached to it are killed.
 func (a *App) Start() error {
-	return http.ListenAndServe(
-		a.port, a.wrapper,
-	)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- a.server.ListenAndServe()
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	select {
+	case err := <-serveErr:
+		a.rootCancel()
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case sig := <-sigCh:
+		slog.Info("Shutdown signal received, draining in-flight requests", "signal", sig.String())
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.Conf.ShutdownTimeout)
+	defer cancel()
+	if err := a.server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Graceful shutdown failed", "error", err)
+	}
+
+	if !a.idle.WaitIdle(config.Conf.ShutdownTimeout) {
+		slog.Warn("Timed out waiting for in-flight requests to drain")
+	}
+
+	a.rootCancel()
+
+	if a.shutdownTracing != nil {
+		if err := a.shutdownTracing(context.Background()); err != nil {
+			slog.Error("Failed to flush traces on shutdown", "error", err)
+		}
+	}
+
+	return nil
 }
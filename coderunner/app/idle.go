@@ -0,0 +1,31 @@
+package app
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// idleTracker counts in-flight requests so Start can wait for them to drain
+// before tearing down the executor/sandbox's root context.
+type idleTracker struct {
+	inFlight atomic.Int64
+}
+
+func (t *idleTracker) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.inFlight.Add(1)
+		defer t.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WaitIdle blocks until there are no in-flight requests, or timeout elapses.
+// It returns whether the tracker reached zero.
+func (t *idleTracker) WaitIdle(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for t.inFlight.Load() > 0 && time.Now().Before(deadline) {
+		time.Sleep(25 * time.Millisecond)
+	}
+	return t.inFlight.Load() == 0
+}
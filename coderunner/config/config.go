@@ -2,12 +2,70 @@ package config
 
 import (
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// ExecutorBackend selects how code is executed.
+type ExecutorBackend string
+
+const (
+	// ExecutorBackendSSH shells out to a remote host over SSH (legacy path).
+	ExecutorBackendSSH ExecutorBackend = "ssh"
+	// ExecutorBackendDocker talks to the local Docker Engine API directly.
+	ExecutorBackendDocker ExecutorBackend = "docker"
+)
+
+// LanguageImage describes how to run a single request's code: which image to
+// run it in, and the argv prefix that invokes the interpreter on the code
+// file copied into the container.
+type LanguageImage struct {
+	Image       string
+	Interpreter []string
+}
+
+// SandboxConfig bounds a single sandboxed run: the per-language image
+// registry plus the resource limits applied to every container it starts.
+type SandboxConfig struct {
+	LanguageImages map[string]LanguageImage
+	NanoCPUs       int64
+	MemoryBytes    int64
+	PidsLimit      int64
+	Timeout        time.Duration
+	OutputByteCap  int64
+	UseGVisor      bool
+	SeccompProfile string
+}
+
+func defaultLanguageImages() map[string]LanguageImage {
+	return map[string]LanguageImage{
+		"python": {Image: "python:3.12-slim", Interpreter: []string{"python3"}},
+		"node":   {Image: "node:20-alpine", Interpreter: []string{"node"}},
+		"go":     {Image: "golang:1.22", Interpreter: []string{"go", "run"}},
+	}
+}
+
+// PoolConfig sizes the warm sandbox container pool (see states.Pool).
+type PoolConfig struct {
+	SizePerLanguage     map[string]int
+	HealthCheckInterval time.Duration
+}
+
 type Config struct {
-	RemoteHosts []string
-	AppUserName string
+	RemoteHosts     []string
+	AppUserName     string
+	ExecutorBackend ExecutorBackend
+	Sandbox         SandboxConfig
+	Pool            PoolConfig
+	RequestTimeout  time.Duration
+	ShutdownTimeout time.Duration
+}
+
+// UseDockerExecutor reports whether the Docker Engine API executor should be
+// used instead of the legacy SSH path.
+func (c *Config) UseDockerExecutor() bool {
+	return c.ExecutorBackend == ExecutorBackendDocker
 }
 
 func (c *Config) ReloadConfig() {
@@ -16,10 +74,56 @@ func (c *Config) ReloadConfig() {
 
 func newConfig() *Config {
 	remoteHosts := os.Getenv("REMOTE_HOSTS")
+	backend := ExecutorBackend(os.Getenv("EXECUTOR_BACKEND"))
+	if backend == "" {
+		backend = ExecutorBackendSSH
+	}
 	return &Config{
-		RemoteHosts: strings.Split(remoteHosts, ";"),
-		AppUserName: os.Getenv("APP_USER_NAME"),
+		RemoteHosts:     strings.Split(remoteHosts, ";"),
+		AppUserName:     os.Getenv("APP_USER_NAME"),
+		ExecutorBackend: backend,
+		Sandbox:         newSandboxConfig(),
+		Pool:            newPoolConfig(),
+		RequestTimeout:  time.Duration(envInt64("REQUEST_TIMEOUT_SECONDS", 30)) * time.Second,
+		ShutdownTimeout: time.Duration(envInt64("SHUTDOWN_TIMEOUT_SECONDS", 30)) * time.Second,
+	}
+}
+
+func newPoolConfig() PoolConfig {
+	size := int(envInt64("POOL_SIZE_PER_LANGUAGE", 2))
+	sizes := make(map[string]int, len(defaultLanguageImages()))
+	for lang := range defaultLanguageImages() {
+		sizes[lang] = size
+	}
+	return PoolConfig{
+		SizePerLanguage:     sizes,
+		HealthCheckInterval: time.Duration(envInt64("POOL_HEALTH_CHECK_INTERVAL_SECONDS", 15)) * time.Second,
+	}
+}
+
+func newSandboxConfig() SandboxConfig {
+	return SandboxConfig{
+		LanguageImages: defaultLanguageImages(),
+		NanoCPUs:       envInt64("SANDBOX_NANO_CPUS", 1_000_000_000),
+		MemoryBytes:    envInt64("SANDBOX_MEMORY_BYTES", 256*1024*1024),
+		PidsLimit:      envInt64("SANDBOX_PIDS_LIMIT", 64),
+		Timeout:        time.Duration(envInt64("SANDBOX_TIMEOUT_SECONDS", 10)) * time.Second,
+		OutputByteCap:  envInt64("SANDBOX_OUTPUT_BYTE_CAP", 64*1024),
+		UseGVisor:      os.Getenv("SANDBOX_USE_GVISOR") == "true",
+		SeccompProfile: os.Getenv("SANDBOX_SECCOMP_PROFILE"),
+	}
+}
+
+func envInt64(key string, fallback int64) int64 {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+	value, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return fallback
 	}
+	return value
 }
 
 var Conf = newConfig()
@@ -1,11 +1,13 @@
 package common
 
 import (
+	"context"
 	"math/rand"
 	"os/exec"
 	"strings"
 
 	"github.com/mosteligible/mcp-codemode/coderunner/config"
+	"github.com/mosteligible/mcp-codemode/coderunner/core/executor"
 	"github.com/mosteligible/mcp-codemode/coderunner/core/types"
 )
 
@@ -21,7 +23,7 @@ func GetAnAvailableRemoteHost() string {
 	return config.Conf.RemoteHosts[rand.Intn(len(config.Conf.RemoteHosts))]
 }
 
-func ExecuteCommand(instruction string) types.CommandOutput {
+func ExecuteCommand(ctx context.Context, instruction string) types.CommandOutput {
 	output := types.CommandOutput{}
 	// execute the command and capture the output and error
 	instruction = strings.TrimSpace(instruction)
@@ -30,10 +32,18 @@ func ExecuteCommand(instruction string) types.CommandOutput {
 		return output
 	}
 
+	if config.Conf.UseDockerExecutor() && executor.Default != nil {
+		return executor.Default.Execute(ctx, instruction)
+	}
+
 	remoteHost := GetAnAvailableRemoteHost()
 	instruction = config.Conf.AppUserName + "@" + remoteHost + " '" + instruction + "'"
 
-	cmd := exec.Command(
+	// exec.CommandContext ties the ssh process to the request's context, so a
+	// client disconnect or a shutdown's root context cancellation kills it
+	// via cmd.Process.Kill() instead of leaving it running.
+	cmd := exec.CommandContext(
+		ctx,
 		"ssh",
 		"-t",
 		instruction,
@@ -0,0 +1,223 @@
+// Package executor talks to the local Docker Engine API directly instead of
+// shelling out to `docker` or `ssh`. It keeps a states.ContainerState in sync
+// with the daemon by subscribing to the Docker events stream, and runs code
+// inside a pooled container via ContainerExecCreate/ContainerExecAttach.
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+
+	coderunnertypes "github.com/mosteligible/mcp-codemode/coderunner/core/types"
+	"github.com/mosteligible/mcp-codemode/coderunner/observability"
+	"github.com/mosteligible/mcp-codemode/coderunner/states"
+)
+
+// reconcileInterval is how often the fallback reconciliation pass runs. It
+// only needs to cover the window where the event stream is disconnected and
+// reconnecting, so it can be much coarser than the old 10s poll.
+const reconcileInterval = 30 * time.Second
+
+// Executor runs code against containers managed through the Docker Engine
+// API. It is the Docker-backed counterpart to common.ExecuteCommand's SSH
+// path.
+type Executor struct {
+	cli        *client.Client
+	containers *states.ContainerState
+}
+
+// Default is the process-wide Executor, set by App.init when the Docker
+// backend is selected. It is nil when running the SSH backend.
+var Default *Executor
+
+// New constructs an Executor backed by the Docker client configured from the
+// environment (DOCKER_HOST, DOCKER_CERT_PATH, etc.) and starts watching for
+// container lifecycle events in the background.
+func New(ctx context.Context, containers *states.ContainerState) (*Executor, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("executor: failed to create docker client: %w", err)
+	}
+
+	e := &Executor{
+		cli:        cli,
+		containers: containers,
+	}
+
+	if err := e.reconcile(ctx); err != nil {
+		slog.Error("executor: initial reconciliation failed", "error", err)
+	}
+
+	go e.watchEvents(ctx)
+	go e.reconcileLoop(ctx)
+
+	return e, nil
+}
+
+// watchEvents subscribes to the Docker events stream and reactively updates
+// the container pool on start/die/destroy, instead of polling `docker ps`.
+func (e *Executor) watchEvents(ctx context.Context) {
+	eventFilter := filters.NewArgs(filters.Arg("type", "container"))
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		msgs, errs := e.cli.Events(ctx, types.EventsOptions{Filters: eventFilter})
+		e.consumeEvents(ctx, msgs, errs)
+
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Warn("executor: event stream disconnected, reconnecting", "backoff", time.Second)
+		time.Sleep(time.Second)
+	}
+}
+
+func (e *Executor) consumeEvents(ctx context.Context, msgs <-chan events.Message, errs <-chan error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err, ok := <-errs:
+			if !ok {
+				return
+			}
+			if err != nil && err != io.EOF {
+				slog.Error("executor: event stream error", "error", err)
+			}
+			return
+		case msg, ok := <-msgs:
+			if !ok {
+				return
+			}
+			e.handleEvent(msg)
+		}
+	}
+}
+
+func (e *Executor) handleEvent(msg events.Message) {
+	switch msg.Action {
+	case "start":
+		e.containers.AddContainer(msg.Actor.ID)
+	case "die", "destroy":
+		e.containers.RemoveContainer(msg.Actor.ID)
+	}
+}
+
+// reconcileLoop periodically rebuilds the container list from scratch as a
+// fallback in case the event stream misses updates while reconnecting.
+func (e *Executor) reconcileLoop(ctx context.Context) {
+	ticker := time.NewTicker(reconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.reconcile(ctx); err != nil {
+				slog.Error("executor: reconciliation failed", "error", err)
+			}
+		}
+	}
+}
+
+func (e *Executor) reconcile(ctx context.Context) error {
+	running, err := e.cli.ContainerList(ctx, container.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("executor: list containers: %w", err)
+	}
+
+	ids := make([]string, 0, len(running))
+	for _, c := range running {
+		ids = append(ids, c.ID)
+	}
+	e.containers.ReplaceAll(ids)
+	return nil
+}
+
+// Execute runs instruction inside a container drawn from the pool and
+// returns its stdout as Output and its stderr, if any, as ErrorMessage.
+func (e *Executor) Execute(ctx context.Context, instruction string) coderunnertypes.CommandOutput {
+	start := time.Now()
+	ctx, span := observability.Tracer.Start(ctx, "executor.Execute")
+	defer span.End()
+
+	output := coderunnertypes.CommandOutput{}
+
+	containerID := e.containers.GetAContainer()
+	if containerID == "" {
+		output.ErrorMessage = "No container available"
+		return output
+	}
+
+	execConfig := types.ExecConfig{
+		Cmd:          []string{"sh", "-c", instruction},
+		AttachStdout: true,
+		AttachStderr: true,
+	}
+
+	execID, err := e.cli.ContainerExecCreate(ctx, containerID, execConfig)
+	if err != nil {
+		output.Err = err
+		output.ErrorMessage = err.Error()
+		return output
+	}
+
+	attachResp, err := e.cli.ContainerExecAttach(ctx, execID.ID, types.ExecStartCheck{})
+	if err != nil {
+		output.Err = err
+		output.ErrorMessage = err.Error()
+		return output
+	}
+	defer attachResp.Close()
+
+	// attachResp.Reader is a hijacked net.Conn: ctx only governs the dial,
+	// not a read already in flight, so StdCopy would otherwise block until
+	// the stream closes on its own regardless of ctx's deadline. Race it
+	// against ctx.Done() and kill the container to force the blocked read
+	// to return, mirroring what exec.CommandContext gives the SSH path for
+	// free.
+	var stdout, stderr strings.Builder
+	copyDone := make(chan error, 1)
+	go func() {
+		_, err := stdcopy.StdCopy(&stdout, &stderr, attachResp.Reader)
+		copyDone <- err
+	}()
+
+	select {
+	case err := <-copyDone:
+		if err != nil && err != io.EOF {
+			output.Err = err
+			output.ErrorMessage = err.Error()
+			return output
+		}
+	case <-ctx.Done():
+		_ = e.cli.ContainerKill(context.Background(), containerID, "KILL")
+		attachResp.Close()
+		<-copyDone
+		output.Err = ctx.Err()
+		output.ErrorMessage = "execution timed out"
+		return output
+	}
+
+	if stderr.Len() > 0 {
+		output.ErrorMessage = stderr.String()
+	}
+	output.Output = stdout.String()
+	output.DurationMS = time.Since(start).Milliseconds()
+	return output
+}
@@ -1,14 +1,21 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 
+	"github.com/mosteligible/mcp-codemode/coderunner/config"
 	"github.com/mosteligible/mcp-codemode/coderunner/core/common"
+	"github.com/mosteligible/mcp-codemode/coderunner/core/sandbox"
 	"github.com/mosteligible/mcp-codemode/coderunner/core/types"
+	"github.com/mosteligible/mcp-codemode/coderunner/observability"
 )
 
 func RunCode(w http.ResponseWriter, r *http.Request) {
+	ctx, span := observability.Tracer.Start(r.Context(), "handlers.RunCode")
+	defer span.End()
+
 	var codeRequest types.CodeRunnerRequest
 
 	err := json.NewDecoder(r.Body).Decode(&codeRequest)
@@ -17,7 +24,26 @@ func RunCode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	output := common.ExecuteCommand(codeRequest.Code)
+	// bound the run by a configurable deadline, and abort it immediately if
+	// the client disconnects, rather than leaving it to consume a container
+	// slot for no one.
+	ctx, cancel := context.WithTimeout(ctx, config.Conf.RequestTimeout)
+	defer cancel()
+
+	var output types.CommandOutput
+	if codeRequest.Language != "" && sandbox.Default != nil {
+		output = sandbox.Default.Run(ctx, codeRequest)
+	} else {
+		output = common.ExecuteCommand(ctx, codeRequest.Code)
+	}
+
+	status := "ok"
+	if output.ErrorMessage != "" {
+		status = "error"
+	}
+	observability.RequestsTotal.WithLabelValues(codeRequest.Language, status).Inc()
+	observability.ExecDuration.WithLabelValues(codeRequest.Language).Observe(float64(output.DurationMS) / 1000)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(output)
 }
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+
+	"github.com/mosteligible/mcp-codemode/coderunner/core/sandbox"
+	"github.com/mosteligible/mcp-codemode/coderunner/core/types"
+)
+
+// StreamRun handles POST /run/stream. Unlike RunCode it does not wait for
+// the run to finish: it writes each Frame to the response as it arrives and
+// flushes after every frame, framed the same way Docker frames an attach stream (an 8-byte
+// header of stream type + 3 reserved bytes + big-endian uint32 size,
+// followed by that many bytes of payload). The final frame is always a
+// system frame carrying the JSON-encoded exit status.
+func StreamRun(w http.ResponseWriter, r *http.Request) {
+	var codeRequest types.CodeRunnerRequest
+	if err := json.NewDecoder(r.Body).Decode(&codeRequest); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if sandbox.Default == nil {
+		http.Error(w, "Streaming execution is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	frames, err := sandbox.Default.Execute(r.Context(), codeRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, canFlush := w.(http.Flusher)
+	for frame := range frames {
+		if err := writeFrame(w, frame); err != nil {
+			return
+		}
+		if canFlush {
+			flusher.Flush()
+		}
+	}
+}
+
+func writeFrame(w http.ResponseWriter, frame types.Frame) error {
+	header := make([]byte, 8)
+	header[0] = byte(frame.Stream)
+	binary.BigEndian.PutUint32(header[4:8], uint32(len(frame.Data)))
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(frame.Data)
+	return err
+}
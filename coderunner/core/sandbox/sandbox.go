@@ -0,0 +1,415 @@
+// Package sandbox runs untrusted request code inside a locked-down,
+// per-language container: read-only rootfs, no network, hard CPU/memory/pids
+// limits, and (when available) the gVisor runtime.
+package sandbox
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+
+	"github.com/mosteligible/mcp-codemode/coderunner/config"
+	coderunnertypes "github.com/mosteligible/mcp-codemode/coderunner/core/types"
+	"github.com/mosteligible/mcp-codemode/coderunner/observability"
+	"github.com/mosteligible/mcp-codemode/coderunner/states"
+)
+
+const (
+	workDir  = "/workspace"
+	codeFile = "run"
+)
+
+// gvisorRuntime is the Docker runtime name registered for runsc.
+const gvisorRuntime = "runsc"
+
+// Sandbox runs request code in a locked-down container for the request's
+// language. When pool is set, containers are checked out of that warm pool
+// instead of being created fresh for every request; pool stays nil to fall
+// back to the original container-per-request behavior (e.g. the pool failed
+// to start).
+type Sandbox struct {
+	cli  *client.Client
+	pool *states.Pool
+}
+
+// Default is the process-wide Sandbox, set by App.init at startup.
+var Default *Sandbox
+
+// New constructs a Sandbox backed by the Docker client configured from the
+// environment. pool may be nil, in which case every run gets a fresh
+// container that is destroyed afterward.
+func New(pool *states.Pool) (*Sandbox, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("sandbox: failed to create docker client: %w", err)
+	}
+	return &Sandbox{cli: cli, pool: pool}, nil
+}
+
+// Execute runs req.Code and streams its output as it is produced. The final
+// frame on the returned channel is always a StreamSystem frame carrying a
+// JSON-encoded SystemNotice. Run is built on top of this and simply
+// aggregates the channel into one batch CommandOutput.
+func (s *Sandbox) Execute(ctx context.Context, req coderunnertypes.CodeRunnerRequest) (<-chan coderunnertypes.Frame, error) {
+	lang, ok := config.Conf.Sandbox.LanguageImages[req.Language]
+	if !ok {
+		return nil, fmt.Errorf("sandbox: unsupported language %q", req.Language)
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, config.Conf.Sandbox.Timeout)
+
+	if s.pool != nil {
+		frames, err := s.executePooled(ctx, runCtx, cancel, req, lang)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		return frames, nil
+	}
+	return s.executeFresh(ctx, runCtx, cancel, req, lang)
+}
+
+// executeFresh creates a brand new container for this run and destroys it
+// afterward. This is the original, always-correct-but-slower path used when
+// no warm pool is configured.
+func (s *Sandbox) executeFresh(ctx, runCtx context.Context, cancel context.CancelFunc, req coderunnertypes.CodeRunnerRequest, lang config.LanguageImage) (<-chan coderunnertypes.Frame, error) {
+	start := time.Now()
+
+	filePath := workDir + "/" + codeFile
+	containerID, err := s.createContainer(runCtx, lang, append(append([]string{}, lang.Interpreter...), filePath))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	if err := s.copyCodeToContainer(runCtx, containerID, req.Code); err != nil {
+		cancel()
+		s.cli.ContainerRemove(context.Background(), containerID, container.RemoveOptions{Force: true})
+		return nil, err
+	}
+
+	if err := s.cli.ContainerStart(runCtx, containerID, container.StartOptions{}); err != nil {
+		cancel()
+		s.cli.ContainerRemove(context.Background(), containerID, container.RemoveOptions{Force: true})
+		return nil, err
+	}
+
+	frames := make(chan coderunnertypes.Frame)
+	go func() {
+		defer cancel()
+		defer close(frames)
+		defer s.cli.ContainerRemove(context.Background(), containerID, container.RemoveOptions{Force: true})
+
+		var truncated bool
+		logsReader, err := s.cli.ContainerLogs(runCtx, containerID, container.LogsOptions{
+			ShowStdout: true,
+			ShowStderr: true,
+			Follow:     true,
+		})
+		if err == nil {
+			demuxFrames(runCtx, logsReader, frames, config.Conf.Sandbox.OutputByteCap, &truncated)
+			logsReader.Close()
+		}
+
+		exitCode, timedOut := s.wait(runCtx, containerID)
+		// Sent under ctx, not runCtx: after a timeout runCtx is already done,
+		// and the timeout notice still has to reach the reader.
+		s.sendSystemFrame(ctx, frames, start, exitCode, timedOut, truncated)
+	}()
+
+	return frames, nil
+}
+
+// executePooled checks out a warm container from the pool and runs the code
+// via ContainerExecCreate/Attach, returning the container wiped-and-reusable
+// or destroyed-and-replaced depending on how the run went.
+func (s *Sandbox) executePooled(ctx, runCtx context.Context, cancel context.CancelFunc, req coderunnertypes.CodeRunnerRequest, lang config.LanguageImage) (<-chan coderunnertypes.Frame, error) {
+	start := time.Now()
+
+	checkoutCtx, checkoutSpan := observability.Tracer.Start(runCtx, "sandbox.checkout")
+	pooled, err := s.pool.Checkout(checkoutCtx, req.Language)
+	checkoutSpan.End()
+	if err != nil {
+		return nil, fmt.Errorf("sandbox: checkout pooled container: %w", err)
+	}
+	observability.PoolAvailable.WithLabelValues(req.Language).Set(float64(s.pool.Depth(req.Language)))
+
+	if err := s.copyCodeToContainer(runCtx, pooled.ID, req.Code); err != nil {
+		s.returnPooled(pooled, false)
+		return nil, err
+	}
+
+	execCtx, execSpan := observability.Tracer.Start(runCtx, "sandbox.exec")
+	defer execSpan.End()
+
+	filePath := workDir + "/" + codeFile
+	execConfig := types.ExecConfig{
+		Cmd:          append(append([]string{}, lang.Interpreter...), filePath),
+		WorkingDir:   workDir,
+		AttachStdout: true,
+		AttachStderr: true,
+	}
+	execID, err := s.cli.ContainerExecCreate(execCtx, pooled.ID, execConfig)
+	if err != nil {
+		s.returnPooled(pooled, false)
+		return nil, fmt.Errorf("sandbox: exec create: %w", err)
+	}
+
+	attachResp, err := s.cli.ContainerExecAttach(execCtx, execID.ID, types.ExecStartCheck{})
+	if err != nil {
+		s.returnPooled(pooled, false)
+		return nil, fmt.Errorf("sandbox: exec attach: %w", err)
+	}
+
+	frames := make(chan coderunnertypes.Frame)
+	go func() {
+		defer cancel()
+		defer close(frames)
+		defer attachResp.Close()
+
+		streamCtx, streamSpan := observability.Tracer.Start(runCtx, "sandbox.stream")
+		var truncated bool
+
+		// attachResp.Reader is a hijacked net.Conn: ctx only governs the
+		// dial, not reads already in flight, so a stuck exec (e.g. an
+		// infinite loop) blocks demuxFrames forever no matter what runCtx
+		// does. Race it against runCtx.Done() and close the connection out
+		// from under it to force the blocked read to return.
+		demuxDone := make(chan struct{})
+		go func() {
+			defer close(demuxDone)
+			demuxFrames(streamCtx, attachResp.Reader, frames, config.Conf.Sandbox.OutputByteCap, &truncated)
+		}()
+
+		select {
+		case <-demuxDone:
+		case <-runCtx.Done():
+			attachResp.Close()
+			<-demuxDone
+		}
+		streamSpan.End()
+
+		exitCode, timedOut := s.waitExec(runCtx, execID.ID)
+		s.returnPooled(pooled, !timedOut && exitCode == 0)
+		s.sendSystemFrame(ctx, frames, start, exitCode, timedOut, truncated)
+	}()
+
+	return frames, nil
+}
+
+// returnPooled hands the container back to the pool and keeps the pool
+// metrics in sync with what actually happened to it.
+func (s *Sandbox) returnPooled(pooled *states.PooledContainer, reusable bool) {
+	lang := pooled.Language
+	replacementsBefore := s.pool.Replacements()
+
+	s.pool.Return(context.Background(), pooled, reusable)
+
+	if s.pool.Replacements() > replacementsBefore {
+		observability.ContainerReplacementsTotal.Inc()
+	}
+	observability.PoolAvailable.WithLabelValues(lang).Set(float64(s.pool.Depth(lang)))
+}
+
+func (s *Sandbox) sendSystemFrame(ctx context.Context, frames chan<- coderunnertypes.Frame, start time.Time, exitCode int, timedOut, truncated bool) {
+	notice, _ := json.Marshal(coderunnertypes.SystemNotice{
+		ExitCode:   exitCode,
+		DurationMS: time.Since(start).Milliseconds(),
+		TimedOut:   timedOut,
+		Truncated:  truncated,
+	})
+	select {
+	case frames <- coderunnertypes.Frame{Stream: coderunnertypes.StreamSystem, Data: notice}:
+	case <-ctx.Done():
+	}
+}
+
+// Run executes req.Code in a locked-down container for req.Language (checked
+// out of the warm pool when s has one, otherwise created fresh) and returns its output, exit code and wall-clock duration as one batch
+// result, by aggregating Execute's frame stream.
+func (s *Sandbox) Run(ctx context.Context, req coderunnertypes.CodeRunnerRequest) coderunnertypes.CommandOutput {
+	output := coderunnertypes.CommandOutput{}
+
+	frames, err := s.Execute(ctx, req)
+	if err != nil {
+		output.Err = err
+		output.ErrorMessage = err.Error()
+		return output
+	}
+
+	var stdout, stderr strings.Builder
+	for frame := range frames {
+		switch frame.Stream {
+		case coderunnertypes.StreamStdout:
+			stdout.Write(frame.Data)
+		case coderunnertypes.StreamStderr:
+			stderr.Write(frame.Data)
+		case coderunnertypes.StreamSystem:
+			var notice coderunnertypes.SystemNotice
+			if err := json.Unmarshal(frame.Data, &notice); err == nil {
+				output.ExitCode = notice.ExitCode
+				output.DurationMS = notice.DurationMS
+				output.Truncated = notice.Truncated
+				if notice.TimedOut {
+					output.ErrorMessage = "execution timed out"
+				}
+			}
+		}
+	}
+
+	output.Output = stdout.String()
+	if stderr.Len() > 0 && output.ErrorMessage == "" {
+		output.ErrorMessage = stderr.String()
+	}
+	return output
+}
+
+func (s *Sandbox) createContainer(ctx context.Context, lang config.LanguageImage, cmd []string) (string, error) {
+	sandboxCfg := config.Conf.Sandbox
+
+	hostConfig := &container.HostConfig{
+		ReadonlyRootfs: true,
+		NetworkMode:    "none",
+		Tmpfs:          map[string]string{"/tmp": "size=64m", workDir: "size=16m"},
+		SecurityOpt:    []string{"no-new-privileges:true"},
+		Resources: container.Resources{
+			NanoCPUs:  sandboxCfg.NanoCPUs,
+			Memory:    sandboxCfg.MemoryBytes,
+			PidsLimit: &sandboxCfg.PidsLimit,
+		},
+	}
+	if sandboxCfg.SeccompProfile != "" {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "seccomp="+sandboxCfg.SeccompProfile)
+	}
+	if sandboxCfg.UseGVisor {
+		hostConfig.Runtime = gvisorRuntime
+	}
+
+	resp, err := s.cli.ContainerCreate(ctx, &container.Config{
+		Image:      lang.Image,
+		Cmd:        cmd,
+		WorkingDir: workDir,
+	}, hostConfig, nil, nil, "")
+	if err != nil {
+		return "", fmt.Errorf("sandbox: create container: %w", err)
+	}
+	return resp.ID, nil
+}
+
+func (s *Sandbox) copyCodeToContainer(ctx context.Context, containerID, code string) error {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	header := &tar.Header{
+		Name: codeFile,
+		Mode: 0o644,
+		Size: int64(len(code)),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("sandbox: write tar header: %w", err)
+	}
+	if _, err := tw.Write([]byte(code)); err != nil {
+		return fmt.Errorf("sandbox: write tar body: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("sandbox: close tar: %w", err)
+	}
+
+	if err := s.cli.CopyToContainer(ctx, containerID, workDir, &buf, types.CopyToContainerOptions{}); err != nil {
+		return fmt.Errorf("sandbox: copy code to container: %w", err)
+	}
+	return nil
+}
+
+// wait blocks until the container exits or the run context's deadline fires,
+// killing the container on timeout.
+func (s *Sandbox) wait(ctx context.Context, containerID string) (exitCode int, timedOut bool) {
+	statusCh, errCh := s.cli.ContainerWait(ctx, containerID, container.WaitConditionNotRunning)
+	select {
+	case <-ctx.Done():
+		_ = s.cli.ContainerKill(context.Background(), containerID, "KILL")
+		return -1, true
+	case err := <-errCh:
+		if err != nil {
+			return -1, false
+		}
+	case status := <-statusCh:
+		return int(status.StatusCode), false
+	}
+	return -1, false
+}
+
+// waitExec polls ContainerExecInspect until the exec finishes or ctx is done,
+// in which case it reports timedOut. It does not kill the container itself;
+// the caller then returns it as not reusable, so the pool destroys and
+// replaces it.
+func (s *Sandbox) waitExec(ctx context.Context, execID string) (exitCode int, timedOut bool) {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return -1, true
+		case <-ticker.C:
+			inspect, err := s.cli.ContainerExecInspect(ctx, execID)
+			if err != nil {
+				return -1, false
+			}
+			if !inspect.Running {
+				return inspect.ExitCode, false
+			}
+		}
+	}
+}
+
+// demuxFrames reads Docker's framed attach format (an 8-byte header — stream
+// type, 3 reserved bytes, big-endian uint32 size — followed by size bytes of
+// payload) and forwards each chunk as a Frame, enforcing cap bytes across
+// both streams combined. It returns once a read from r fails (including EOF),
+// or when ctx is done while it is waiting to deliver a frame; a read already
+// blocked on r is not interrupted by ctx.
+func demuxFrames(ctx context.Context, r io.Reader, out chan<- coderunnertypes.Frame, cap int64, truncated *bool) {
+	header := make([]byte, 8)
+	var written int64
+
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return
+		}
+		streamType := coderunnertypes.StreamType(header[0])
+		size := binary.BigEndian.Uint32(header[4:8])
+
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return
+		}
+
+		if cap > 0 {
+			if written >= cap {
+				*truncated = true
+				continue
+			}
+			if remaining := cap - written; int64(len(payload)) > remaining {
+				payload = payload[:remaining]
+				*truncated = true
+			}
+			written += int64(len(payload))
+		}
+
+		select {
+		case out <- coderunnertypes.Frame{Stream: streamType, Data: payload}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
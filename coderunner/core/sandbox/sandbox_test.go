@@ -0,0 +1,90 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+
+	coderunnertypes "github.com/mosteligible/mcp-codemode/coderunner/core/types"
+)
+
+// dockerFrame builds one frame of Docker's attach wire format: an 8-byte
+// header (stream type, 3 reserved bytes, big-endian uint32 size) followed by
+// the payload.
+func dockerFrame(stream coderunnertypes.StreamType, payload string) []byte {
+	header := make([]byte, 8)
+	header[0] = byte(stream)
+	binary.BigEndian.PutUint32(header[4:8], uint32(len(payload)))
+	return append(header, payload...)
+}
+
+func TestDemuxFramesSplitsStdoutAndStderr(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write(dockerFrame(coderunnertypes.StreamStdout, "hello "))
+	buf.Write(dockerFrame(coderunnertypes.StreamStderr, "oops"))
+	buf.Write(dockerFrame(coderunnertypes.StreamStdout, "world"))
+
+	out := make(chan coderunnertypes.Frame, 10)
+	var truncated bool
+	demuxFrames(context.Background(), &buf, out, 0, &truncated)
+	close(out)
+
+	var stdout, stderr string
+	for f := range out {
+		switch f.Stream {
+		case coderunnertypes.StreamStdout:
+			stdout += string(f.Data)
+		case coderunnertypes.StreamStderr:
+			stderr += string(f.Data)
+		}
+	}
+
+	if stdout != "hello world" {
+		t.Errorf("stdout = %q, want %q", stdout, "hello world")
+	}
+	if stderr != "oops" {
+		t.Errorf("stderr = %q, want %q", stderr, "oops")
+	}
+	if truncated {
+		t.Error("truncated = true, want false")
+	}
+}
+
+func TestDemuxFramesEnforcesByteCap(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write(dockerFrame(coderunnertypes.StreamStdout, "0123456789"))
+	buf.Write(dockerFrame(coderunnertypes.StreamStdout, "abcdefghij"))
+
+	out := make(chan coderunnertypes.Frame, 10)
+	var truncated bool
+	demuxFrames(context.Background(), &buf, out, 15, &truncated)
+	close(out)
+
+	var got string
+	for f := range out {
+		got += string(f.Data)
+	}
+
+	if got != "0123456789abcde" {
+		t.Errorf("output = %q, want %q", got, "0123456789abcde")
+	}
+	if !truncated {
+		t.Error("truncated = false, want true")
+	}
+}
+
+func TestDemuxFramesStopsOnShortRead(t *testing.T) {
+	// A truncated header (fewer than 8 bytes) should make demuxFrames
+	// return instead of blocking or panicking.
+	buf := bytes.NewReader([]byte{0x01, 0x00, 0x00})
+
+	out := make(chan coderunnertypes.Frame, 10)
+	var truncated bool
+	demuxFrames(context.Background(), buf, out, 0, &truncated)
+	close(out)
+
+	if _, ok := <-out; ok {
+		t.Error("expected no frames from a truncated header")
+	}
+}
@@ -5,8 +5,38 @@ type CodeRunnerRequest struct {
 	Language string `json:"language"`
 }
 
+// StreamType identifies which channel a streamed Frame came from, matching
+// Docker's attach wire format.
+type StreamType uint8
+
+const (
+	StreamStdout StreamType = 1
+	StreamStderr StreamType = 2
+	// StreamSystem carries out-of-band notices (exit status, timeouts) that
+	// didn't come from the running process itself.
+	StreamSystem StreamType = 3
+)
+
+// Frame is one chunk of streamed output, as produced by Execute.
+type Frame struct {
+	Stream StreamType
+	Data   []byte
+}
+
+// SystemNotice is the JSON payload carried by the final StreamSystem frame
+// of a streamed run.
+type SystemNotice struct {
+	ExitCode   int   `json:"exit_code"`
+	DurationMS int64 `json:"duration_ms"`
+	TimedOut   bool  `json:"timed_out,omitempty"`
+	Truncated  bool  `json:"truncated,omitempty"`
+}
+
 type CommandOutput struct {
 	Output       string `json:"output"`
 	ErrorMessage string `json:"error,omitempty"`
 	Err          error  `json:"-"`
+	ExitCode     int    `json:"exit_code"`
+	DurationMS   int64  `json:"duration_ms"`
+	Truncated    bool   `json:"truncated,omitempty"`
 }
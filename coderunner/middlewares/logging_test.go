@@ -0,0 +1,36 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestLoggingMiddlewarePreservesFlush guards against statusRecorder losing
+// the underlying writer's http.Flusher implementation, which previously made
+// handlers.StreamRun's per-frame flush silently become a no-op once the
+// logging middleware wrapped the response writer.
+func TestLoggingMiddlewarePreservesFlush(t *testing.T) {
+	var canFlush bool
+	streaming := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("chunk"))
+		flusher, ok := w.(http.Flusher)
+		canFlush = ok
+		if ok {
+			flusher.Flush()
+		}
+	})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/run/stream", nil)
+
+	LoggingMiddleware(streaming).ServeHTTP(rec, req)
+
+	if !canFlush {
+		t.Fatal("handler could not type-assert http.Flusher through LoggingMiddleware")
+	}
+	if !rec.Flushed {
+		t.Fatal("Flush() did not reach the underlying ResponseWriter")
+	}
+}
@@ -0,0 +1,48 @@
+// Package observability wires up the Prometheus metrics and OpenTelemetry
+// tracing shared across the executor path: handlers.RunCode, sandbox/executor
+// checkout, container exec, and output streaming.
+package observability
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// RequestsTotal counts code execution requests by language and outcome
+	// ("ok" or "error").
+	RequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "coderunner_requests_total",
+		Help: "Total number of code execution requests, by language and status.",
+	}, []string{"lang", "status"})
+
+	// ExecDuration tracks how long a run took, by language.
+	ExecDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "coderunner_exec_duration_seconds",
+		Help:    "Execution duration in seconds, by language.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"lang"})
+
+	// PoolAvailable is the number of free (checked-in) containers currently
+	// sitting in the sandbox pool, by language.
+	PoolAvailable = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "coderunner_pool_available",
+		Help: "Number of free containers currently available in the sandbox pool, by language.",
+	}, []string{"lang"})
+
+	// ContainerReplacementsTotal counts pooled containers destroyed and
+	// replaced, whether from a failed health check or a run that tripped a
+	// resource limit or timeout.
+	ContainerReplacementsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "coderunner_container_replacements_total",
+		Help: "Total number of pooled sandbox containers destroyed and replaced.",
+	})
+)
+
+// Handler serves the Prometheus text exposition format for /metrics.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,42 @@
+package observability
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/mosteligible/mcp-codemode/coderunner"
+
+// Tracer is the process-wide tracer. It's ready to use as a no-op tracer
+// even before InitTracer runs, so instrumented code never needs a nil check.
+var Tracer = otel.Tracer(tracerName)
+
+// InitTracer registers a global TracerProvider and W3C trace-context
+// propagator, and returns a shutdown func to flush spans on exit.
+func InitTracer() (func(context.Context) error, error) {
+	tp := sdktrace.NewTracerProvider()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	Tracer = tp.Tracer(tracerName)
+	return tp.Shutdown, nil
+}
+
+// Middleware extracts an incoming traceparent header (if any) and starts a
+// span covering the whole request, so checkout/exec/streaming spans created
+// downstream of handlers.RunCode nest under it.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		var span oteltrace.Span
+		ctx, span = Tracer.Start(ctx, r.Method+" "+r.URL.Path)
+		defer span.End()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
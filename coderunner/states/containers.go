@@ -7,6 +7,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/mosteligible/mcp-codemode/coderunner/config"
 )
 
 type ContainerState struct {
@@ -20,23 +22,62 @@ func NewContainerState() *ContainerState {
 		readWriteMutex: &sync.RWMutex{},
 	}
 
-	// for a container state, we need to periodically update the available containers
-	// so there are no stale containers in the list. run a goroutine to update the available
-	// containers every 10 seconds
-	go func() {
-		for {
-			err := cs.SetAvailableContainers()
-			if err != nil {
-				slog.Error("Failed to set available containers", "error", err)
-			} else {
-				slog.Info("Successfully updated available containers")
+	// the docker executor keeps Ids up to date reactively off the Docker
+	// events stream (see coderunner/core/executor), so the legacy poller is
+	// only needed for the SSH backend.
+	if !config.Conf.UseDockerExecutor() {
+		// for a container state, we need to periodically update the available containers
+		// so there are no stale containers in the list. run a goroutine to update the available
+		// containers every 10 seconds
+		go func() {
+			for {
+				err := cs.SetAvailableContainers()
+				if err != nil {
+					slog.Error("Failed to set available containers", "error", err)
+				} else {
+					slog.Info("Successfully updated available containers")
+				}
+				time.Sleep(10 * time.Second)
 			}
-			time.Sleep(10 * time.Second)
-		}
-	}()
+		}()
+	}
 	return cs
 }
 
+// AddContainer adds id to the available set if it is not already present.
+// It is used by the Docker executor to react to container "start" events.
+func (s *ContainerState) AddContainer(id string) {
+	s.readWriteMutex.Lock()
+	defer s.readWriteMutex.Unlock()
+	for _, existing := range s.Ids {
+		if existing == id {
+			return
+		}
+	}
+	s.Ids = append(s.Ids, id)
+}
+
+// RemoveContainer drops id from the available set. It is used by the Docker
+// executor to react to container "die"/"destroy" events.
+func (s *ContainerState) RemoveContainer(id string) {
+	s.readWriteMutex.Lock()
+	defer s.readWriteMutex.Unlock()
+	for i, existing := range s.Ids {
+		if existing == id {
+			s.Ids = append(s.Ids[:i], s.Ids[i+1:]...)
+			return
+		}
+	}
+}
+
+// ReplaceAll atomically swaps the available set, used by the executor's
+// periodic reconciliation pass.
+func (s *ContainerState) ReplaceAll(ids []string) {
+	s.readWriteMutex.Lock()
+	defer s.readWriteMutex.Unlock()
+	s.Ids = ids
+}
+
 func (s *ContainerState) GetAContainer() string {
 	s.readWriteMutex.RLock()
 	defer s.readWriteMutex.RUnlock()
@@ -0,0 +1,332 @@
+package states
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+
+	"github.com/mosteligible/mcp-codemode/coderunner/config"
+	"github.com/mosteligible/mcp-codemode/coderunner/observability"
+)
+
+// PooledContainer is one warm, language-dedicated container checked out of a
+// Pool for a single sandboxed run.
+type PooledContainer struct {
+	ID       string
+	Language string
+}
+
+// Pool is a warm, language-partitioned set of sandbox containers with
+// checkout/return semantics. It replaces creating (and destroying) a
+// container for every request with pre-started containers that get wiped
+// and handed back for reuse between runs.
+type Pool struct {
+	cli *client.Client
+
+	mu      sync.Mutex
+	free    map[string][]*PooledContainer
+	waiters map[string][]chan *PooledContainer
+	sizes   map[string]int
+
+	replacements atomic.Int64
+	lastWaitNS   atomic.Int64
+}
+
+// NewPool constructs an empty Pool sized sizes[language] per language. Call
+// Start to pre-create containers and begin health checking.
+func NewPool(sizes map[string]int) (*Pool, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("states: failed to create docker client: %w", err)
+	}
+	return &Pool{
+		cli:     cli,
+		free:    make(map[string][]*PooledContainer),
+		waiters: make(map[string][]chan *PooledContainer),
+		sizes:   sizes,
+	}, nil
+}
+
+// Start pre-creates sizes[lang] containers per configured language image and
+// launches the background health-check loop. It keeps going on a per-language
+// failure so one missing image doesn't block the rest of the pool.
+func (p *Pool) Start(ctx context.Context) error {
+	var firstErr error
+	for lang, n := range p.sizes {
+		image, ok := config.Conf.Sandbox.LanguageImages[lang]
+		if !ok {
+			continue
+		}
+		for i := 0; i < n; i++ {
+			c, err := p.create(ctx, lang, image)
+			if err != nil {
+				slog.Error("states: pool warm-up failed", "language", lang, "error", err)
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			p.mu.Lock()
+			p.free[lang] = append(p.free[lang], c)
+			p.mu.Unlock()
+		}
+	}
+
+	go p.healthCheckLoop(ctx)
+	return firstErr
+}
+
+// Checkout blocks until a free container for lang is available or ctx is
+// done.
+func (p *Pool) Checkout(ctx context.Context, lang string) (*PooledContainer, error) {
+	start := time.Now()
+
+	p.mu.Lock()
+	if free := p.free[lang]; len(free) > 0 {
+		c := free[len(free)-1]
+		p.free[lang] = free[:len(free)-1]
+		p.mu.Unlock()
+		p.lastWaitNS.Store(int64(time.Since(start)))
+		return c, nil
+	}
+	waiter := make(chan *PooledContainer, 1)
+	p.waiters[lang] = append(p.waiters[lang], waiter)
+	p.mu.Unlock()
+
+	select {
+	case c := <-waiter:
+		p.lastWaitNS.Store(int64(time.Since(start)))
+		return c, nil
+	case <-ctx.Done():
+		p.mu.Lock()
+		stillQueued := p.removeWaiterLocked(lang, waiter)
+		p.mu.Unlock()
+		if !stillQueued {
+			// A concurrent Return already popped this waiter and sent to it
+			// (the channel is buffered, so that send never blocked on us);
+			// since we're giving up, the container would otherwise be
+			// dropped on the floor, so hand it to the free list instead.
+			select {
+			case c := <-waiter:
+				p.mu.Lock()
+				p.free[lang] = append(p.free[lang], c)
+				p.mu.Unlock()
+			default:
+			}
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// Return hands c back to the pool. When reusable is true it is wiped
+// (rm -rf /workspace/* /tmp/*) and made available again; otherwise (a tripped
+// resource limit, a timeout, or a failed cleanup) it is destroyed and
+// replaced with a fresh container for the same language.
+func (p *Pool) Return(ctx context.Context, c *PooledContainer, reusable bool) {
+	if reusable {
+		if err := p.clean(ctx, c); err != nil {
+			slog.Error("states: pool cleanup failed, replacing container", "container", c.ID, "error", err)
+			reusable = false
+		}
+	}
+
+	if !reusable {
+		p.replace(ctx, c)
+		return
+	}
+
+	p.handBackOrFree(c)
+}
+
+// clean wipes c's workspace and waits for the cleanup to actually finish
+// before returning, so a caller never hands the container back (or lets it
+// be checked out again) while rm -rf is still racing the next tenant's
+// copyCodeToContainer.
+func (p *Pool) clean(ctx context.Context, c *PooledContainer) error {
+	execID, err := p.cli.ContainerExecCreate(ctx, c.ID, types.ExecConfig{
+		Cmd: []string{"sh", "-c", "rm -rf /workspace/* /tmp/*"},
+	})
+	if err != nil {
+		return fmt.Errorf("exec create: %w", err)
+	}
+
+	// Non-detached: the daemon doesn't respond until the process exits and
+	// its output stream closes, so by the time this returns the cleanup
+	// has actually finished (or ctx was cancelled) instead of racing the
+	// next tenant's copyCodeToContainer.
+	if err := p.cli.ContainerExecStart(ctx, execID.ID, types.ExecStartCheck{}); err != nil {
+		return fmt.Errorf("exec start: %w", err)
+	}
+
+	inspect, err := p.cli.ContainerExecInspect(ctx, execID.ID)
+	if err != nil {
+		return fmt.Errorf("exec inspect: %w", err)
+	}
+	if inspect.Running {
+		return fmt.Errorf("cleanup still running after exec start returned")
+	}
+	if inspect.ExitCode != 0 {
+		return fmt.Errorf("cleanup exited %d", inspect.ExitCode)
+	}
+	return nil
+}
+
+// removeWaiterLocked removes waiter from p.waiters[lang] if it is still
+// queued there, reporting whether it found (and removed) it. Callers must
+// hold p.mu.
+func (p *Pool) removeWaiterLocked(lang string, waiter chan *PooledContainer) bool {
+	waiters := p.waiters[lang]
+	for i, w := range waiters {
+		if w == waiter {
+			p.waiters[lang] = append(waiters[:i], waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Pool) handBackOrFree(c *PooledContainer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if waiters := p.waiters[c.Language]; len(waiters) > 0 {
+		w := waiters[0]
+		p.waiters[c.Language] = waiters[1:]
+		w <- c
+		return
+	}
+	p.free[c.Language] = append(p.free[c.Language], c)
+}
+
+func (p *Pool) replace(ctx context.Context, c *PooledContainer) {
+	_ = p.cli.ContainerRemove(ctx, c.ID, container.RemoveOptions{Force: true})
+	p.replacements.Add(1)
+
+	image, ok := config.Conf.Sandbox.LanguageImages[c.Language]
+	if !ok {
+		return
+	}
+	fresh, err := p.create(ctx, c.Language, image)
+	if err != nil {
+		slog.Error("states: failed to replace pooled container", "language", c.Language, "error", err)
+		return
+	}
+	p.handBackOrFree(fresh)
+}
+
+func (p *Pool) create(ctx context.Context, lang string, image config.LanguageImage) (*PooledContainer, error) {
+	sandboxCfg := config.Conf.Sandbox
+
+	hostConfig := &container.HostConfig{
+		ReadonlyRootfs: true,
+		NetworkMode:    "none",
+		Tmpfs:          map[string]string{"/tmp": "size=64m", "/workspace": "size=16m"},
+		SecurityOpt:    []string{"no-new-privileges:true"},
+		Resources: container.Resources{
+			NanoCPUs:  sandboxCfg.NanoCPUs,
+			Memory:    sandboxCfg.MemoryBytes,
+			PidsLimit: &sandboxCfg.PidsLimit,
+		},
+	}
+	if sandboxCfg.UseGVisor {
+		hostConfig.Runtime = "runsc"
+	}
+
+	resp, err := p.cli.ContainerCreate(ctx, &container.Config{
+		Image:      image.Image,
+		Cmd:        []string{"sleep", "infinity"},
+		WorkingDir: "/workspace",
+	}, hostConfig, nil, nil, "")
+	if err != nil {
+		return nil, fmt.Errorf("create container: %w", err)
+	}
+	if err := p.cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return nil, fmt.Errorf("start container: %w", err)
+	}
+	return &PooledContainer{ID: resp.ID, Language: lang}, nil
+}
+
+// healthCheckLoop periodically inspects free (not checked-out) containers
+// and replaces any that are no longer running.
+func (p *Pool) healthCheckLoop(ctx context.Context) {
+	ticker := time.NewTicker(config.Conf.Pool.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.checkHealth(ctx)
+		}
+	}
+}
+
+func (p *Pool) checkHealth(ctx context.Context) {
+	p.mu.Lock()
+	var snapshot []*PooledContainer
+	for _, list := range p.free {
+		snapshot = append(snapshot, list...)
+	}
+	p.mu.Unlock()
+
+	for _, c := range snapshot {
+		inspect, err := p.cli.ContainerInspect(ctx, c.ID)
+		if err == nil && inspect.State != nil && inspect.State.Running {
+			continue
+		}
+
+		// A concurrent Checkout may have already taken c out of p.free
+		// between the snapshot above and this inspect. Only replace it if
+		// it's still actually ours to replace, otherwise whichever Return
+		// eventually fires for the in-flight request that checked it out
+		// decides its fate, instead of this loop double-replacing it.
+		p.mu.Lock()
+		stillFree := p.removeFreeLocked(c)
+		p.mu.Unlock()
+		if !stillFree {
+			continue
+		}
+		p.replace(ctx, c)
+		observability.ContainerReplacementsTotal.Inc()
+	}
+}
+
+// removeFreeLocked removes c from p.free if it is still there, reporting
+// whether it found (and removed) it. Callers must hold p.mu.
+func (p *Pool) removeFreeLocked(c *PooledContainer) bool {
+	list := p.free[c.Language]
+	for i, existing := range list {
+		if existing.ID == c.ID {
+			p.free[c.Language] = append(list[:i], list[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Depth returns the number of currently free (not checked-out) containers
+// for lang.
+func (p *Pool) Depth(lang string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.free[lang])
+}
+
+// LastWait returns how long the most recent Checkout call had to wait for a
+// free container.
+func (p *Pool) LastWait() time.Duration {
+	return time.Duration(p.lastWaitNS.Load())
+}
+
+// Replacements returns the number of pooled containers destroyed and
+// replaced so far (failed health checks, tripped limits, timeouts).
+func (p *Pool) Replacements() int64 {
+	return p.replacements.Load()
+}
@@ -0,0 +1,149 @@
+package states
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestPool() *Pool {
+	return &Pool{
+		free:    make(map[string][]*PooledContainer),
+		waiters: make(map[string][]chan *PooledContainer),
+	}
+}
+
+func TestCheckoutDeliversQueuedContainerThroughWaiter(t *testing.T) {
+	p := newTestPool()
+
+	type result struct {
+		c   *PooledContainer
+		err error
+	}
+	resCh := make(chan result, 1)
+	go func() {
+		c, err := p.Checkout(context.Background(), "python")
+		resCh <- result{c, err}
+	}()
+
+	waitForWaiters(t, p, "python", 1)
+
+	want := &PooledContainer{ID: "c1", Language: "python"}
+	p.handBackOrFree(want)
+
+	res := <-resCh
+	if res.err != nil {
+		t.Fatalf("Checkout returned error %v, want nil", res.err)
+	}
+	if res.c != want {
+		t.Fatalf("Checkout returned %v, want %v", res.c, want)
+	}
+}
+
+// TestCheckoutCancelDeregistersWaiter guards against a waiter leak: once
+// Checkout gives up because ctx was cancelled, it must not leave its waiter
+// channel registered in p.waiters, or a later Return can hand a container to
+// a channel nobody is reading anymore, losing it permanently.
+func TestCheckoutCancelDeregistersWaiter(t *testing.T) {
+	p := newTestPool()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.Checkout(ctx, "python")
+		done <- err
+	}()
+
+	waitForWaiters(t, p, "python", 1)
+	cancel()
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Checkout error = %v, want context.Canceled", err)
+	}
+
+	p.mu.Lock()
+	n := len(p.waiters["python"])
+	p.mu.Unlock()
+	if n != 0 {
+		t.Fatalf("waiter still registered after Checkout gave up: %d", n)
+	}
+}
+
+// TestReturnToAbandonedWaiterGoesToFreeList covers the case where Return
+// races Checkout's cancellation: handBackOrFree pops the waiter and sends to
+// it (the channel is buffered, so the send never blocks) just before
+// Checkout's ctx.Done() branch runs. The container must not be dropped; it
+// should end up back on the free list instead.
+func TestReturnToAbandonedWaiterGoesToFreeList(t *testing.T) {
+	p := newTestPool()
+	waiter := make(chan *PooledContainer, 1)
+	p.waiters["python"] = append(p.waiters["python"], waiter)
+
+	abandoned := &PooledContainer{ID: "abandoned", Language: "python"}
+	p.handBackOrFree(abandoned)
+
+	// Simulate Checkout's ctx.Done() branch finding the waiter already
+	// removed (handBackOrFree popped it above), which must fall back to
+	// draining the buffered send and re-queuing the container.
+	p.mu.Lock()
+	stillQueued := p.removeWaiterLocked("python", waiter)
+	p.mu.Unlock()
+	if stillQueued {
+		t.Fatal("removeWaiterLocked reported waiter still queued after handBackOrFree popped it")
+	}
+
+	select {
+	case c := <-waiter:
+		p.mu.Lock()
+		p.free["python"] = append(p.free["python"], c)
+		p.mu.Unlock()
+	default:
+		t.Fatal("abandoned waiter channel had no buffered container to drain")
+	}
+
+	if got := p.free["python"]; len(got) != 1 || got[0] != abandoned {
+		t.Fatalf("container not recovered onto the free list, free = %v", got)
+	}
+}
+
+// TestRemoveFreeLockedReportsWhetherContainerWasPresent guards checkHealth's
+// race with a concurrent Checkout: it must only replace a container it
+// actually managed to remove from the free list, not one already handed out.
+func TestRemoveFreeLockedReportsWhetherContainerWasPresent(t *testing.T) {
+	p := newTestPool()
+	c := &PooledContainer{ID: "c1", Language: "python"}
+	p.free["python"] = []*PooledContainer{c}
+
+	p.mu.Lock()
+	removed := p.removeFreeLocked(c)
+	p.mu.Unlock()
+	if !removed {
+		t.Fatal("removeFreeLocked reported false for a container still in the free list")
+	}
+	if len(p.free["python"]) != 0 {
+		t.Fatalf("container not actually removed, free = %v", p.free["python"])
+	}
+
+	p.mu.Lock()
+	removedAgain := p.removeFreeLocked(c)
+	p.mu.Unlock()
+	if removedAgain {
+		t.Fatal("removeFreeLocked reported true for a container already checked out")
+	}
+}
+
+func waitForWaiters(t *testing.T, p *Pool, lang string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		p.mu.Lock()
+		got := len(p.waiters[lang])
+		p.mu.Unlock()
+		if got >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d waiter(s) on %q", n, lang)
+}